@@ -0,0 +1,40 @@
+// Package config reads the environment-driven settings the service needs
+// to start, starting with which storage.Storage backend to wire up.
+package config
+
+import "os"
+
+// StorageDriver selects which storage.Storage backend driver.New builds.
+type StorageDriver string
+
+const (
+	DriverPostgres StorageDriver = "postgres"
+	DriverMemory   StorageDriver = "memory"
+	DriverFile     StorageDriver = "file"
+)
+
+// Config holds the environment-driven settings needed to construct a
+// storage backend.
+type Config struct {
+	StorageDriver StorageDriver
+	PostgresDSN   string
+	FilePath      string
+}
+
+// Load reads Config from the environment. STORAGE_DRIVER selects the
+// backend (postgres|memory|file, default postgres) so the service can run
+// without a database in CI or single-binary deployments.
+func Load() Config {
+	driver := StorageDriver(os.Getenv("STORAGE_DRIVER"))
+	switch driver {
+	case DriverMemory, DriverFile:
+	default:
+		driver = DriverPostgres
+	}
+
+	return Config{
+		StorageDriver: driver,
+		PostgresDSN:   os.Getenv("POSTGRES_DSN"),
+		FilePath:      os.Getenv("STORAGE_FILE_PATH"),
+	}
+}
@@ -0,0 +1,41 @@
+// Package driver selects and constructs a storage.Storage backend from
+// config.Config, so callers don't need to import the individual
+// postgresql/memory/file packages themselves.
+package driver
+
+import (
+	"fmt"
+
+	"url-shortener/internal/config"
+	"url-shortener/internal/storage"
+	"url-shortener/internal/storage/file"
+	"url-shortener/internal/storage/memory"
+	"url-shortener/internal/storage/postgresql"
+)
+
+// New constructs the storage.Storage backend selected by cfg.StorageDriver.
+func New(cfg config.Config) (storage.Storage, error) {
+	const op = "storage.driver.New"
+
+	switch cfg.StorageDriver {
+	case config.DriverMemory:
+		return memory.New(), nil
+
+	case config.DriverFile:
+		s, err := file.New(cfg.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return s, nil
+
+	case config.DriverPostgres:
+		s, err := postgresql.New(cfg.PostgresDSN)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		return s, nil
+
+	default:
+		return nil, fmt.Errorf("%s: unknown storage driver %q", op, cfg.StorageDriver)
+	}
+}
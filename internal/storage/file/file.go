@@ -0,0 +1,380 @@
+// Package file is a JSON-lines file-backed storage.Storage implementation.
+// Every saved URL is appended as one JSON object per line and fsync'd before
+// returning; deletes rewrite the whole file through an fsync'd temp file and
+// a rename. Either way the store survives a process crash without a database.
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/urlutil"
+)
+
+type entry struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id"`
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias"`
+	NURL      string     `json:"nurl"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type visit struct {
+	visitedAt time.Time
+	remoteIP  string
+}
+
+type Storage struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	byAlias map[string]entry
+	byNURL  map[string]string
+	nextID  int64
+
+	visitsMu sync.Mutex
+	visits   map[string][]visit // alias -> events; not persisted to disk
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func New(path string) (*Storage, error) {
+	const op = "storage.file.New"
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s := &Storage{
+		path:    path,
+		file:    f,
+		byAlias: make(map[string]entry),
+		byNURL:  make(map[string]string),
+		visits:  make(map[string][]visit),
+	}
+
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s, nil
+}
+
+func (s *Storage) load() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+
+		s.byAlias[e.Alias] = e
+		if e.DeletedAt == nil {
+			s.byNURL[e.NURL] = e.Alias
+		}
+		if e.ID > s.nextID {
+			s.nextID = e.ID
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *Storage) append(e entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if _, err := s.file.Write(data); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+func (s *Storage) SaveURL(userID, urlToSave, alias string) (int64, error) {
+	const op = "storage.file.SaveURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nurl := urlutil.Normalize(urlToSave)
+	if existingAlias, ok := s.byNURL[nurl]; ok {
+		return 0, fmt.Errorf("%s: %w", op, &storage.URLExistsError{Alias: existingAlias})
+	}
+
+	if _, ok := s.byAlias[alias]; ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	s.nextID++
+	e := entry{ID: s.nextID, UserID: userID, URL: urlToSave, Alias: alias, NURL: nurl, CreatedAt: time.Now()}
+
+	if err := s.append(e); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.byAlias[alias] = e
+	s.byNURL[nurl] = alias
+
+	return e.ID, nil
+}
+
+func (s *Storage) GetURL(alias string) (string, error) {
+	const op = "storage.file.GetURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.byAlias[alias]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	if e.DeletedAt != nil {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLDeleted)
+	}
+
+	return e.URL, nil
+}
+
+func (s *Storage) GetAliasByURL(rawURL string) (string, error) {
+	const op = "storage.file.GetAliasByURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alias, ok := s.byNURL[urlutil.Normalize(rawURL)]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	return alias, nil
+}
+
+// DeleteURL soft-deletes alias and rewrites the log so the tombstone is
+// durable without growing the file unboundedly.
+func (s *Storage) DeleteURL(alias string) error {
+	const op = "storage.file.DeleteURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.byAlias[alias]
+	if !ok || e.DeletedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	now := time.Now()
+	e.DeletedAt = &now
+	s.byAlias[alias] = e
+	delete(s.byNURL, e.NURL)
+
+	if err := s.rewrite(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ListURLsByUser returns userID's non-deleted URLs, most recently created
+// first.
+func (s *Storage) ListURLsByUser(userID string, limit, offset int) ([]storage.URLRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var all []storage.URLRecord
+	for _, e := range s.byAlias {
+		if e.UserID != userID || e.DeletedAt != nil {
+			continue
+		}
+		all = append(all, storage.URLRecord{
+			ID:        e.ID,
+			UserID:    e.UserID,
+			URL:       e.URL,
+			Alias:     e.Alias,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(all) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(all) || limit <= 0 {
+		end = len(all)
+	}
+
+	return all[offset:end], nil
+}
+
+// DeleteURLsByUser soft-deletes aliases belonging to userID and rewrites
+// the log once for the whole batch.
+func (s *Storage) DeleteURLsByUser(userID string, aliases []string) error {
+	const op = "storage.file.DeleteURLsByUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	changed := false
+	for _, alias := range aliases {
+		e, ok := s.byAlias[alias]
+		if !ok || e.UserID != userID || e.DeletedAt != nil {
+			continue
+		}
+
+		e.DeletedAt = &now
+		s.byAlias[alias] = e
+		delete(s.byNURL, e.NURL)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := s.rewrite(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) rewrite() error {
+	tmpPath := s.path + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range s.byAlias {
+		data, err := json.Marshal(e)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		data = append(data, '\n')
+
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+
+	s.file.Close()
+	newFile, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.file = newFile
+
+	return nil
+}
+
+// SaveURLBatch delegates to SaveURL item by item: each call already
+// appends-and-fsyncs its own entry, so there's no batching to gain from
+// special-casing this beyond reusing storage.BatchResult for per-item errors.
+func (s *Storage) SaveURLBatch(items []storage.BatchItem) ([]storage.BatchResult, error) {
+	const op = "storage.file.SaveURLBatch"
+
+	results := make([]storage.BatchResult, len(items))
+	for i, item := range items {
+		id, err := s.SaveURL(item.UserID, item.URL, item.Alias)
+		if err != nil {
+			results[i] = storage.BatchResult{Err: fmt.Errorf("%s: %w", op, err)}
+			continue
+		}
+
+		results[i] = storage.BatchResult{ID: id}
+	}
+
+	return results, nil
+}
+
+// RecordVisit keeps click events in memory only; unlike URLs, visits are
+// not written to the JSON-lines log.
+func (s *Storage) RecordVisit(alias string, v storage.VisitInfo) error {
+	s.visitsMu.Lock()
+	defer s.visitsMu.Unlock()
+
+	s.visits[alias] = append(s.visits[alias], visit{visitedAt: time.Now(), remoteIP: v.RemoteIP})
+
+	return nil
+}
+
+// GetStats aggregates alias's visits map (never persisted to the JSON-lines
+// log) into total clicks, distinct remote IPs, and a 30-day daily histogram.
+func (s *Storage) GetStats(alias string) (storage.Stats, error) {
+	s.visitsMu.Lock()
+	defer s.visitsMu.Unlock()
+
+	cutoff := time.Now().AddDate(0, 0, -30)
+	uniqueIPs := make(map[string]struct{})
+	daily := make(map[time.Time]int64)
+
+	var stats storage.Stats
+	for _, e := range s.visits[alias] {
+		stats.TotalClicks++
+		uniqueIPs[e.remoteIP] = struct{}{}
+
+		if e.visitedAt.After(cutoff) {
+			day := time.Date(e.visitedAt.Year(), e.visitedAt.Month(), e.visitedAt.Day(), 0, 0, 0, 0, e.visitedAt.Location())
+			daily[day]++
+		}
+	}
+	stats.UniqueIPs = int64(len(uniqueIPs))
+
+	days := make([]time.Time, 0, len(daily))
+	for d := range daily {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	for _, d := range days {
+		stats.Daily = append(stats.Daily, storage.DailyCount{Date: d, Clicks: daily[d]})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,239 @@
+package file
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"url-shortener/internal/storage"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	s, err := New(filepath.Join(t.TempDir(), "urls.jsonl"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestSaveAndGetURL(t *testing.T) {
+	s := newTestStorage(t)
+
+	id, err := s.SaveURL("user-1", "https://example.com", "ex")
+	if err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	if id == 0 {
+		t.Fatal("expected non-zero id")
+	}
+
+	got, err := s.GetURL("ex")
+	if err != nil {
+		t.Fatalf("GetURL: %v", err)
+	}
+	if got != "https://example.com" {
+		t.Errorf("GetURL = %q, want %q", got, "https://example.com")
+	}
+}
+
+func TestSaveURL_duplicateAlias(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "ex"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	_, err := s.SaveURL("user-1", "https://example.com/b", "ex")
+	if !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("SaveURL duplicate alias: got %v, want ErrURLExists", err)
+	}
+}
+
+func TestSaveURL_duplicateURLReturnsExistingAlias(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "first"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	_, err := s.SaveURL("user-1", "https://EXAMPLE.com/a", "second")
+
+	var existsErr *storage.URLExistsError
+	if !errors.As(err, &existsErr) {
+		t.Fatalf("SaveURL duplicate url: got %v, want *storage.URLExistsError", err)
+	}
+	if existsErr.Alias != "first" {
+		t.Errorf("URLExistsError.Alias = %q, want %q", existsErr.Alias, "first")
+	}
+}
+
+func TestGetURL_notFound(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.GetURL("missing"); !errors.Is(err, storage.ErrURLNotFound) {
+		t.Errorf("GetURL missing: got %v, want ErrURLNotFound", err)
+	}
+}
+
+func TestDeleteURL_softDeletesAndFreesNURL(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "ex"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	if err := s.DeleteURL("ex"); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+
+	if _, err := s.GetURL("ex"); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("GetURL after delete: got %v, want ErrURLDeleted", err)
+	}
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "ex2"); err != nil {
+		t.Errorf("SaveURL after delete: got %v, want nil", err)
+	}
+}
+
+func TestDeleteURL_persistsAcrossReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.jsonl")
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "ex"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	if err := s.DeleteURL("ex"); err != nil {
+		t.Fatalf("DeleteURL: %v", err)
+	}
+
+	reloaded, err := New(path)
+	if err != nil {
+		t.Fatalf("New (reload): %v", err)
+	}
+	if _, err := reloaded.GetURL("ex"); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("GetURL after reload: got %v, want ErrURLDeleted", err)
+	}
+}
+
+func TestSaveURLBatch_perItemErrors(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "taken"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	results, err := s.SaveURLBatch([]storage.BatchItem{
+		{URL: "https://example.com/b", Alias: "ok"},
+		{URL: "https://example.com/c", Alias: "taken"},
+	})
+	if err != nil {
+		t.Fatalf("SaveURLBatch: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("results[0].Err = %v, want nil", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, storage.ErrURLExists) {
+		t.Errorf("results[1].Err = %v, want ErrURLExists", results[1].Err)
+	}
+}
+
+func TestSaveURLBatch_ownsItemsByUserID(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURLBatch([]storage.BatchItem{
+		{UserID: "user-1", URL: "https://example.com/a", Alias: "a"},
+	}); err != nil {
+		t.Fatalf("SaveURLBatch: %v", err)
+	}
+
+	records, err := s.ListURLsByUser("user-1", 10, 0)
+	if err != nil {
+		t.Fatalf("ListURLsByUser: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record owned by user-1, got %d", len(records))
+	}
+}
+
+func TestListAndDeleteURLsByUser(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "a"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	if _, err := s.SaveURL("user-1", "https://example.com/b", "b"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+	if _, err := s.SaveURL("user-2", "https://example.com/c", "c"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	records, err := s.ListURLsByUser("user-1", 10, 0)
+	if err != nil {
+		t.Fatalf("ListURLsByUser: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records for user-1, got %d", len(records))
+	}
+
+	if err := s.DeleteURLsByUser("user-1", []string{"a", "b"}); err != nil {
+		t.Fatalf("DeleteURLsByUser: %v", err)
+	}
+
+	records, err = s.ListURLsByUser("user-1", 10, 0)
+	if err != nil {
+		t.Fatalf("ListURLsByUser after delete: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected 0 records for user-1 after delete, got %d", len(records))
+	}
+}
+
+func TestListURLsByUser_negativeOffsetDoesNotPanic(t *testing.T) {
+	s := newTestStorage(t)
+
+	if _, err := s.SaveURL("user-1", "https://example.com/a", "a"); err != nil {
+		t.Fatalf("SaveURL: %v", err)
+	}
+
+	records, err := s.ListURLsByUser("user-1", 10, -5)
+	if err != nil {
+		t.Fatalf("ListURLsByUser: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("expected 1 record, got %d", len(records))
+	}
+}
+
+func TestRecordVisitAndGetStats(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.RecordVisit("ex", storage.VisitInfo{RemoteIP: "1.1.1.1"}); err != nil {
+		t.Fatalf("RecordVisit: %v", err)
+	}
+	if err := s.RecordVisit("ex", storage.VisitInfo{RemoteIP: "1.1.1.1"}); err != nil {
+		t.Fatalf("RecordVisit: %v", err)
+	}
+	if err := s.RecordVisit("ex", storage.VisitInfo{RemoteIP: "2.2.2.2"}); err != nil {
+		t.Fatalf("RecordVisit: %v", err)
+	}
+
+	stats, err := s.GetStats("ex")
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.TotalClicks != 3 {
+		t.Errorf("TotalClicks = %d, want 3", stats.TotalClicks)
+	}
+	if stats.UniqueIPs != 2 {
+		t.Errorf("UniqueIPs = %d, want 2", stats.UniqueIPs)
+	}
+}
@@ -0,0 +1,248 @@
+// Package memory is a sync.Map-backed storage.Storage implementation for
+// tests and small deployments that don't need a real database.
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/internal/storage"
+	"url-shortener/internal/urlutil"
+)
+
+type record struct {
+	id        int64
+	userID    string
+	url       string
+	nurl      string
+	alias     string
+	createdAt time.Time
+	deletedAt *time.Time
+}
+
+type visit struct {
+	visitedAt time.Time
+	remoteIP  string
+}
+
+type Storage struct {
+	byAlias sync.Map // alias (string) -> *record
+	byNURL  sync.Map // normalized url (string) -> alias (string)
+	visits  sync.Map // alias (string) -> *visitLog
+	nextID  int64
+}
+
+type visitLog struct {
+	mu     sync.Mutex
+	events []visit
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func New() *Storage {
+	return &Storage{}
+}
+
+func (s *Storage) SaveURL(userID, urlToSave, alias string) (int64, error) {
+	const op = "storage.memory.SaveURL"
+
+	if existingAlias, err := s.GetAliasByURL(urlToSave); err == nil {
+		return 0, fmt.Errorf("%s: %w", op, &storage.URLExistsError{Alias: existingAlias})
+	} else if !errors.Is(err, storage.ErrURLNotFound) {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	nurl := urlutil.Normalize(urlToSave)
+	id := atomic.AddInt64(&s.nextID, 1)
+	rec := &record{id: id, userID: userID, url: urlToSave, nurl: nurl, alias: alias, createdAt: time.Now()}
+
+	if _, loaded := s.byAlias.LoadOrStore(alias, rec); loaded {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	if existing, loaded := s.byNURL.LoadOrStore(nurl, alias); loaded {
+		s.byAlias.Delete(alias)
+		return 0, fmt.Errorf("%s: %w", op, &storage.URLExistsError{Alias: existing.(string)})
+	}
+
+	return id, nil
+}
+
+func (s *Storage) GetURL(alias string) (string, error) {
+	const op = "storage.memory.GetURL"
+
+	v, ok := s.byAlias.Load(alias)
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	rec := v.(*record)
+	if rec.deletedAt != nil {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLDeleted)
+	}
+
+	return rec.url, nil
+}
+
+// DeleteURL soft-deletes alias: it stays in the index but GetURL and
+// GetAliasByURL report it as ErrURLDeleted / not found from then on.
+func (s *Storage) DeleteURL(alias string) error {
+	const op = "storage.memory.DeleteURL"
+
+	v, ok := s.byAlias.Load(alias)
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	rec := v.(*record)
+	if rec.deletedAt != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	now := time.Now()
+	rec.deletedAt = &now
+	s.byNURL.Delete(rec.nurl)
+
+	return nil
+}
+
+func (s *Storage) GetAliasByURL(rawURL string) (string, error) {
+	const op = "storage.memory.GetAliasByURL"
+
+	v, ok := s.byNURL.Load(urlutil.Normalize(rawURL))
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	return v.(string), nil
+}
+
+// SaveURLBatch has no pipelining to optimize for here (there's no round
+// trip), so it's a plain loop over SaveURL with per-item results.
+func (s *Storage) SaveURLBatch(items []storage.BatchItem) ([]storage.BatchResult, error) {
+	const op = "storage.memory.SaveURLBatch"
+
+	results := make([]storage.BatchResult, len(items))
+	for i, item := range items {
+		id, err := s.SaveURL(item.UserID, item.URL, item.Alias)
+		if err != nil {
+			results[i] = storage.BatchResult{Err: fmt.Errorf("%s: %w", op, err)}
+			continue
+		}
+
+		results[i] = storage.BatchResult{ID: id}
+	}
+
+	return results, nil
+}
+
+// ListURLsByUser returns userID's non-deleted URLs, most recently created
+// first.
+func (s *Storage) ListURLsByUser(userID string, limit, offset int) ([]storage.URLRecord, error) {
+	var all []storage.URLRecord
+	s.byAlias.Range(func(_, v any) bool {
+		rec := v.(*record)
+		if rec.userID == userID && rec.deletedAt == nil {
+			all = append(all, storage.URLRecord{
+				ID:        rec.id,
+				UserID:    rec.userID,
+				URL:       rec.url,
+				Alias:     rec.alias,
+				CreatedAt: rec.createdAt,
+			})
+		}
+		return true
+	})
+
+	sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(all) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(all) || limit <= 0 {
+		end = len(all)
+	}
+
+	return all[offset:end], nil
+}
+
+// DeleteURLsByUser soft-deletes aliases belonging to userID.
+func (s *Storage) DeleteURLsByUser(userID string, aliases []string) error {
+	for _, alias := range aliases {
+		v, ok := s.byAlias.Load(alias)
+		if !ok {
+			continue
+		}
+
+		rec := v.(*record)
+		if rec.userID != userID || rec.deletedAt != nil {
+			continue
+		}
+
+		now := time.Now()
+		rec.deletedAt = &now
+		s.byNURL.Delete(rec.nurl)
+	}
+
+	return nil
+}
+
+func (s *Storage) RecordVisit(alias string, v storage.VisitInfo) error {
+	val, _ := s.visits.LoadOrStore(alias, &visitLog{})
+	log := val.(*visitLog)
+
+	log.mu.Lock()
+	log.events = append(log.events, visit{visitedAt: time.Now(), remoteIP: v.RemoteIP})
+	log.mu.Unlock()
+
+	return nil
+}
+
+// GetStats walks the in-memory visit log for alias, tallying total clicks,
+// distinct remote IPs, and a per-day histogram capped to the last 30 days.
+func (s *Storage) GetStats(alias string) (storage.Stats, error) {
+	val, ok := s.visits.Load(alias)
+	if !ok {
+		return storage.Stats{}, nil
+	}
+	log := val.(*visitLog)
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	cutoff := time.Now().AddDate(0, 0, -30)
+	uniqueIPs := make(map[string]struct{})
+	daily := make(map[time.Time]int64)
+
+	var stats storage.Stats
+	for _, e := range log.events {
+		stats.TotalClicks++
+		uniqueIPs[e.remoteIP] = struct{}{}
+
+		if e.visitedAt.After(cutoff) {
+			day := time.Date(e.visitedAt.Year(), e.visitedAt.Month(), e.visitedAt.Day(), 0, 0, 0, 0, e.visitedAt.Location())
+			daily[day]++
+		}
+	}
+	stats.UniqueIPs = int64(len(uniqueIPs))
+
+	days := make([]time.Time, 0, len(daily))
+	for d := range daily {
+		days = append(days, d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	for _, d := range days {
+		stats.Daily = append(stats.Daily, storage.DailyCount{Date: d, Clicks: daily[d]})
+	}
+
+	return stats, nil
+}
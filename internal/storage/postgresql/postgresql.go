@@ -8,14 +8,50 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"sync"
 	"time"
 	"url-shortener/internal/storage"
+	"url-shortener/internal/urlutil"
+)
+
+const (
+	deleteFlushInterval = 500 * time.Millisecond
+	visitBatchSize      = 100
+	visitFlushInterval  = time.Second
 )
 
 type Storage struct {
 	db *pgxpool.Pool
+
+	deleteCh   chan deleteRequest
+	deleteDone chan struct{}
+
+	visitCh   chan visitRecord
+	visitDone chan struct{}
+
+	// closeMu guards closed: Close takes it for writing before closing
+	// deleteCh/visitCh, and every send into those channels takes it for
+	// reading first, so a send can never race a close and panic.
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+type deleteRequest struct {
+	userID string
+	alias  string
+}
+
+type visitRecord struct {
+	alias     string
+	visitedAt time.Time
+	remoteIP  string
+	userAgent string
+	referer   string
 }
 
+var _ storage.Storage = (*Storage)(nil)
+
 func New(connStr string) (*Storage, error) {
 	const op = "storage.postgresql.New"
 
@@ -39,34 +75,202 @@ func New(connStr string) (*Storage, error) {
 	_, err = db.Exec(ctx, `
 	CREATE TABLE IF NOT EXISTS url(
 	    id SERIAL PRIMARY KEY,
-	    alias TEXT NOT NULL UNIQUE,
+	    alias TEXT NOT NULL,
 	    url TEXT NOT NULL);
-	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
+
+	-- Миграции для инстансов, у которых таблица url уже была создана более
+	-- старой версией: CREATE TABLE IF NOT EXISTS выше ничего не добавляет
+	-- к существующей таблице, так что новые столбцы накатываются отдельно.
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS nurl TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS user_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+
+	-- alias изначально объявлялся как UNIQUE-колонка; снимаем это
+	-- ограничение в пользу частичного индекса ниже.
+	ALTER TABLE url DROP CONSTRAINT IF EXISTS url_alias_key;
+	DROP INDEX IF EXISTS idx_alias;
+	DROP INDEX IF EXISTS idx_nurl;
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Rows written before the nurl column existed all defaulted to '', which
+	// would collide on the unique index below. Backfill them with their
+	// real normalized URL first.
+	if err := backfillNURL(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = db.Exec(ctx, `
+	-- Partial: a soft-deleted row's alias/nurl must not block re-saving the
+	-- same URL or reusing the alias.
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_alias ON url(alias) WHERE deleted_at IS NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_nurl ON url(nurl) WHERE deleted_at IS NULL;
+	CREATE INDEX IF NOT EXISTS idx_user_id ON url(user_id);
+
+	CREATE TABLE IF NOT EXISTS visits(
+	    alias TEXT NOT NULL,
+	    visited_at TIMESTAMP NOT NULL,
+	    remote_ip TEXT NOT NULL,
+	    user_agent TEXT NOT NULL,
+	    referer TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_visits_alias ON visits(alias);
 	`)
 	if err != nil {
 		db.Close()
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	s := &Storage{
+		db:         db,
+		deleteCh:   make(chan deleteRequest, 256),
+		deleteDone: make(chan struct{}),
+		visitCh:    make(chan visitRecord, 1024),
+		visitDone:  make(chan struct{}),
+	}
+	go s.runDeleteWorker()
+	go s.runVisitWorker()
+
+	return s, nil
+}
+
+// backfillNURL fills in nurl for any row still left at its column default
+// ('') by a pre-nurl binary, so the partial unique index New creates right
+// after can actually be created against it. If two such rows normalize to
+// the same URL, only the oldest (lowest id) keeps it; the rest are
+// soft-deleted so they don't collide with the index, the same outcome a
+// fresh SaveURL call would produce for a duplicate today.
+func backfillNURL(ctx context.Context, db *pgxpool.Pool) error {
+	const op = "storage.postgresql.backfillNURL"
+
+	rows, err := db.Query(ctx, `SELECT id, url FROM url WHERE nurl = '' AND deleted_at IS NULL ORDER BY id;`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	type legacyRow struct {
+		id  int64
+		url string
+	}
+
+	var pending []legacyRow
+	for rows.Next() {
+		var r legacyRow
+		if err := rows.Scan(&r.id, &r.url); err != nil {
+			rows.Close()
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		pending = append(pending, r)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	rows.Close()
+
+	seen := make(map[string]bool, len(pending))
+	for _, r := range pending {
+		nurl := urlutil.Normalize(r.url)
+
+		if seen[nurl] {
+			_, err := db.Exec(ctx, `UPDATE url SET nurl = $1, deleted_at = now() WHERE id = $2;`, nurl, r.id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			continue
+		}
+
+		seen[nurl] = true
+		if _, err := db.Exec(ctx, `UPDATE url SET nurl = $1 WHERE id = $2;`, nurl, r.id); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// Close stops the background bulk-delete and visit-flush workers, flushing
+// whatever is still pending, or returns ctx's error if they aren't done in
+// time. It waits out any RecordVisit/DeleteURLsByUser call already in
+// flight before closing their channels, so those never panic on a send to
+// a closed channel.
+func (s *Storage) Close(ctx context.Context) error {
+	s.closeMu.Lock()
+	if s.closed {
+		s.closeMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.closeMu.Unlock()
+
+	close(s.deleteCh)
+	close(s.visitCh)
+
+	for _, done := range []chan struct{}{s.deleteDone, s.visitDone} {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
 }
 
-func (s *Storage) SaveURL(urlToSave, alias string) (int64, error) {
+// SaveURL runs the duplicate check and the insert in one transaction, so a
+// concurrent save of the same URL can only ever be observed as a unique
+// violation on the INSERT, never as two successful inserts. If that violation
+// lands on idx_nurl — another transaction won the same race — we look up
+// its alias and report URLExistsError instead of a bare ErrURLExists.
+func (s *Storage) SaveURL(userID, urlToSave, alias string) (int64, error) {
 	const op = "storage.postgresql.SaveURL"
-	var id int64
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := s.db.QueryRow(ctx, `
-		INSERT INTO url(url, alias) 
-		VALUES($1, $2) 
+	nurl := urlutil.Normalize(urlToSave)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Если нормализованный URL уже был сокращён раньше, возвращаем
+	// существующий alias вместо создания дубликата
+	var existingAlias string
+	err = tx.QueryRow(ctx, `SELECT alias FROM url WHERE nurl = $1 AND deleted_at IS NULL;`, nurl).Scan(&existingAlias)
+	switch {
+	case err == nil:
+		return 0, fmt.Errorf("%s: %w", op, &storage.URLExistsError{Alias: existingAlias})
+	case errors.Is(err, pgx.ErrNoRows):
+		// ничего не найдено — продолжаем вставку
+	default:
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var id int64
+	err = tx.QueryRow(ctx, `
+		INSERT INTO url(url, alias, nurl, user_id)
+		VALUES($1, $2, $3, $4)
 		RETURNING id;
-	`, urlToSave, alias).Scan(&id)
+	`, urlToSave, alias, nurl, userID).Scan(&id)
 	if err != nil {
-		// Обработка ошибки уникальности (если alias уже существует)
+		// Обработка ошибки уникальности (если alias или nurl уже существуют)
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			if pgErr.ConstraintName == "idx_nurl" {
+				// Конкурентная вставка успела сохранить тот же nurl первой —
+				// подтягиваем её alias, а не отдаём голый ErrURLExists
+				if winnerAlias, lookupErr := s.GetAliasByURL(urlToSave); lookupErr == nil {
+					return 0, fmt.Errorf("%s: %w", op, &storage.URLExistsError{Alias: winnerAlias})
+				}
+			}
+
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
 		}
 
@@ -78,17 +282,99 @@ func (s *Storage) SaveURL(urlToSave, alias string) (int64, error) {
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return id, nil
 }
 
+// GetAliasByURL looks up the alias an already-shortened, non-deleted URL
+// was assigned, matching on its normalized form so equivalent URLs (case,
+// default port, query-key order) are recognized as the same link.
+func (s *Storage) GetAliasByURL(rawURL string) (string, error) {
+	const op = "storage.postgresql.GetAliasByURL"
+	var alias string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	nurl := urlutil.Normalize(rawURL)
+
+	err := s.db.QueryRow(ctx, `SELECT alias FROM url WHERE nurl = $1 AND deleted_at IS NULL;`, nurl).Scan(&alias)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+		}
+
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return alias, nil
+}
+
+// SaveURLBatch inserts many URL/alias pairs in a single round trip using
+// pgx's pipelined batching. Every INSERT carries ON CONFLICT DO NOTHING, so
+// a row whose alias or normalized URL is already taken does not abort the
+// others: it is reported back as that row's BatchResult.Err, as
+// URLExistsError with the existing alias when its URL is already shortened,
+// else as ErrURLExists.
+func (s *Storage) SaveURLBatch(items []storage.BatchItem) ([]storage.BatchResult, error) {
+	const op = "storage.postgresql.SaveURLBatch"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	batch := &pgx.Batch{}
+	for _, item := range items {
+		batch.Queue(`
+			INSERT INTO url(url, alias, nurl, user_id)
+			VALUES($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING
+			RETURNING id;
+		`, item.URL, item.Alias, urlutil.Normalize(item.URL), item.UserID)
+	}
+
+	br := s.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	results := make([]storage.BatchResult, len(items))
+	for i, item := range items {
+		var id int64
+		if err := br.QueryRow().Scan(&id); err != nil {
+			// Конфликт по alias или nurl: ON CONFLICT DO NOTHING вернул пустой
+			// результат вместо ошибки, которая откатила бы весь батч
+			if errors.Is(err, pgx.ErrNoRows) {
+				if existingAlias, lookupErr := s.GetAliasByURL(item.URL); lookupErr == nil {
+					results[i] = storage.BatchResult{Err: fmt.Errorf("%s: %w", op, &storage.URLExistsError{Alias: existingAlias})}
+					continue
+				}
+
+				results[i] = storage.BatchResult{Err: fmt.Errorf("%s: %w", op, storage.ErrURLExists)}
+				continue
+			}
+
+			results[i] = storage.BatchResult{Err: fmt.Errorf("%s: %w", op, err)}
+			continue
+		}
+
+		results[i] = storage.BatchResult{ID: id}
+	}
+
+	return results, nil
+}
+
 func (s *Storage) GetURL(alias string) (string, error) {
 	const op = "storage.postgresql.GetURL"
 	var url string
+	var deletedAt *time.Time
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := s.db.QueryRow(ctx, `SELECT url FROM url WHERE alias = $1;`, alias).Scan(&url)
+	// Алиас мог быть переиспользован после soft-delete: живая строка важнее
+	// удалённых
+	err := s.db.QueryRow(ctx, `SELECT url, deleted_at FROM url WHERE alias = $1 ORDER BY deleted_at IS NULL DESC, id DESC LIMIT 1;`, alias).Scan(&url, &deletedAt)
 	if err != nil {
 		// Обработка ошибки отсутствия строки по ключу (запись не найдена)
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -98,16 +384,22 @@ func (s *Storage) GetURL(alias string) (string, error) {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
+	if deletedAt != nil {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrURLDeleted)
+	}
+
 	return url, nil
 }
 
+// DeleteURL soft-deletes alias by stamping deleted_at instead of removing
+// the row, so GetURL can tell "never existed" from "was removed".
 func (s *Storage) DeleteURL(alias string) error {
 	const op = "storage.postgresql.DeleteURL"
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := s.db.Exec(ctx, `DELETE FROM url WHERE alias = $1`, alias)
+	result, err := s.db.Exec(ctx, `UPDATE url SET deleted_at = now() WHERE alias = $1 AND deleted_at IS NULL`, alias)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -120,3 +412,266 @@ func (s *Storage) DeleteURL(alias string) error {
 
 	return nil
 }
+
+// ListURLsByUser returns userID's non-deleted URLs, most recent first.
+func (s *Storage) ListURLsByUser(userID string, limit, offset int) ([]storage.URLRecord, error) {
+	const op = "storage.postgresql.ListURLsByUser"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, user_id, url, alias, created_at
+		FROM url
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3;
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var records []storage.URLRecord
+	for rows.Next() {
+		var rec storage.URLRecord
+		if err := rows.Scan(&rec.ID, &rec.UserID, &rec.URL, &rec.Alias, &rec.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return records, nil
+}
+
+// DeleteURLsByUser queues (userID, alias) pairs for soft-deletion and
+// returns without waiting for the flush, so the DELETE endpoint stays
+// responsive under load; runDeleteWorker drains the queue in the
+// background. The user_id filter travels with each alias all the way to
+// the UPDATE so a caller can never soft-delete another user's URL.
+func (s *Storage) DeleteURLsByUser(userID string, aliases []string) error {
+	const op = "storage.postgresql.DeleteURLsByUser"
+
+	// Держим closeMu на чтении, пока шлём в deleteCh, чтобы Close не успел
+	// закрыть канал между проверкой s.closed и самой отправкой.
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+
+	if s.closed {
+		return nil
+	}
+
+	for _, alias := range aliases {
+		req := deleteRequest{userID: userID, alias: alias}
+		select {
+		case s.deleteCh <- req:
+		default:
+			// Канал переполнен — сбрасываем эту порцию синхронно, чтобы не потерять alias
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			err := s.flushDeletes(ctx, []deleteRequest{req})
+			cancel()
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runDeleteWorker drains deleteCh, batching (userID, alias) pairs and
+// flushing them each tick.
+func (s *Storage) runDeleteWorker() {
+	defer close(s.deleteDone)
+
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	var pending []deleteRequest
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.flushDeletes(ctx, pending); err != nil {
+			log.Printf("storage.postgresql: flush delete batch: %v", err)
+		}
+		cancel()
+		pending = nil
+	}
+
+	for {
+		select {
+		case req, ok := <-s.deleteCh:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, req)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flushDeletes groups reqs by userID so the UPDATE can filter on
+// user_id = $1 as well as alias = ANY($2) — a request can only ever
+// soft-delete aliases it actually owns.
+func (s *Storage) flushDeletes(ctx context.Context, reqs []deleteRequest) error {
+	const op = "storage.postgresql.flushDeletes"
+
+	byUser := make(map[string][]string, len(reqs))
+	for _, req := range reqs {
+		byUser[req.userID] = append(byUser[req.userID], req.alias)
+	}
+
+	for userID, aliases := range byUser {
+		_, err := s.db.Exec(ctx, `
+			UPDATE url SET deleted_at = now()
+			WHERE user_id = $1 AND alias = ANY($2) AND deleted_at IS NULL
+		`, userID, aliases)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// RecordVisit buffers a redirect event to be written asynchronously, so a
+// slow stats write never adds latency to the redirect itself. If the
+// buffer is full, or Close has already been called, the visit is dropped
+// rather than blocking the caller or panicking on a closed channel.
+func (s *Storage) RecordVisit(alias string, v storage.VisitInfo) error {
+	rec := visitRecord{
+		alias:     alias,
+		visitedAt: time.Now(),
+		remoteIP:  v.RemoteIP,
+		userAgent: v.UserAgent,
+		referer:   v.Referer,
+	}
+
+	// Держим closeMu на чтении, пока шлём в visitCh, чтобы Close не успел
+	// закрыть канал между проверкой s.closed и самой отправкой.
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+
+	if s.closed {
+		return nil
+	}
+
+	select {
+	case s.visitCh <- rec:
+	default:
+		log.Printf("storage.postgresql: visit buffer full, dropping visit for %q", alias)
+	}
+
+	return nil
+}
+
+// runVisitWorker drains visitCh and flushes accumulated visits with
+// pgx.CopyFrom, either once visitBatchSize events have queued up or every
+// visitFlushInterval, whichever comes first.
+func (s *Storage) runVisitWorker() {
+	defer close(s.visitDone)
+
+	ticker := time.NewTicker(visitFlushInterval)
+	defer ticker.Stop()
+
+	var batch []visitRecord
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.flushVisits(ctx, batch); err != nil {
+			log.Printf("storage.postgresql: flush visit batch: %v", err)
+		}
+		cancel()
+		batch = nil
+	}
+
+	for {
+		select {
+		case rec, ok := <-s.visitCh:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= visitBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (s *Storage) flushVisits(ctx context.Context, batch []visitRecord) error {
+	const op = "storage.postgresql.flushVisits"
+
+	rows := make([][]any, len(batch))
+	for i, rec := range batch {
+		rows[i] = []any{rec.alias, rec.visitedAt, rec.remoteIP, rec.userAgent, rec.referer}
+	}
+
+	_, err := s.db.CopyFrom(ctx,
+		pgx.Identifier{"visits"},
+		[]string{"alias", "visited_at", "remote_ip", "user_agent", "referer"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetStats runs two queries against the visits table: one for the
+// all-time total clicks and distinct remote IPs, one for the per-day
+// histogram over the last 30 days.
+func (s *Storage) GetStats(alias string) (storage.Stats, error) {
+	const op = "storage.postgresql.GetStats"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var stats storage.Stats
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT remote_ip)
+		FROM visits
+		WHERE alias = $1;
+	`, alias).Scan(&stats.TotalClicks, &stats.UniqueIPs)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT date_trunc('day', visited_at)::date AS day, COUNT(*)
+		FROM visits
+		WHERE alias = $1 AND visited_at >= now() - interval '30 days'
+		GROUP BY day
+		ORDER BY day;
+	`, alias)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dc storage.DailyCount
+		if err := rows.Scan(&dc.Date, &dc.Clicks); err != nil {
+			return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+		}
+		stats.Daily = append(stats.Daily, dc)
+	}
+	if err := rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrURLNotFound         = errors.New("url not found")
+	ErrURLExists           = errors.New("url exists")
+	ErrURLAlreadyShortened = errors.New("url already shortened")
+	// ErrURLDeleted is returned instead of ErrURLNotFound for an alias that
+	// was soft-deleted, so callers can render 410 Gone instead of 404.
+	ErrURLDeleted = errors.New("url deleted")
+)
+
+// URLExistsError is returned by SaveURL when the normalized target URL was
+// already shortened under a different call. Callers can type-assert on it
+// to render the existing short link instead of creating a duplicate.
+type URLExistsError struct {
+	Alias string
+}
+
+func (e *URLExistsError) Error() string {
+	return "url already shortened as " + e.Alias
+}
+
+func (e *URLExistsError) Unwrap() error {
+	return ErrURLAlreadyShortened
+}
+
+// BatchItem is a single URL/alias pair submitted to SaveURLBatch, owned by
+// UserID the same way a SaveURL call is.
+type BatchItem struct {
+	UserID string
+	URL    string
+	Alias  string
+}
+
+// BatchResult is the outcome of one BatchItem within SaveURLBatch. Err is
+// set per-index (e.g. to ErrURLExists on a unique violation) so that a
+// single colliding alias doesn't abort the rest of the batch.
+type BatchResult struct {
+	ID  int64
+	Err error
+}
+
+// URLRecord is one row returned by ListURLsByUser.
+type URLRecord struct {
+	ID        int64
+	UserID    string
+	URL       string
+	Alias     string
+	CreatedAt time.Time
+}
+
+// VisitInfo is what a caller knows about a single redirect at the moment
+// it happens.
+type VisitInfo struct {
+	RemoteIP  string
+	UserAgent string
+	Referer   string
+}
+
+// DailyCount is the number of clicks recorded for a single calendar day.
+type DailyCount struct {
+	Date   time.Time
+	Clicks int64
+}
+
+// Stats is the result of GetStats for one alias.
+type Stats struct {
+	TotalClicks int64
+	UniqueIPs   int64
+	Daily       []DailyCount // last 30 days, oldest first
+}
+
+// Storage is implemented by every URL storage backend.
+type Storage interface {
+	SaveURL(userID, urlToSave, alias string) (int64, error)
+	GetURL(alias string) (string, error)
+	DeleteURL(alias string) error
+	SaveURLBatch(items []BatchItem) ([]BatchResult, error)
+	GetAliasByURL(url string) (string, error)
+	ListURLsByUser(userID string, limit, offset int) ([]URLRecord, error)
+	DeleteURLsByUser(userID string, aliases []string) error
+	RecordVisit(alias string, v VisitInfo) error
+	GetStats(alias string) (Stats, error)
+}
@@ -0,0 +1,65 @@
+// Package urlutil provides helpers for canonicalizing URLs so that
+// equivalent links (differing only in case, default port, query-key
+// order, etc.) can be recognized as duplicates.
+package urlutil
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Normalize returns a canonical form of rawURL suitable for duplicate
+// detection: scheme+host+path with the host lowercased, default ports
+// (80 for http, 443 for https) stripped, duplicate slashes in the path
+// collapsed, query keys sorted alphabetically, and the fragment dropped.
+//
+// Normalize is best-effort: if rawURL fails to parse, it is returned
+// unchanged so callers can still store something rather than erroring
+// out of the save path.
+func Normalize(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "http" && strings.HasSuffix(u.Host, ":80"):
+		u.Host = strings.TrimSuffix(u.Host, ":80")
+	case u.Scheme == "https" && strings.HasSuffix(u.Host, ":443"):
+		u.Host = strings.TrimSuffix(u.Host, ":443")
+	}
+
+	for strings.Contains(u.Path, "//") {
+		u.Path = strings.ReplaceAll(u.Path, "//", "/")
+	}
+
+	if u.RawQuery != "" {
+		values := u.Query()
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var sb strings.Builder
+		for _, k := range keys {
+			vals := values[k]
+			sort.Strings(vals)
+			for _, v := range vals {
+				if sb.Len() > 0 {
+					sb.WriteByte('&')
+				}
+				sb.WriteString(url.QueryEscape(k))
+				sb.WriteByte('=')
+				sb.WriteString(url.QueryEscape(v))
+			}
+		}
+		u.RawQuery = sb.String()
+	}
+
+	u.Fragment = ""
+
+	return u.String()
+}
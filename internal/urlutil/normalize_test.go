@@ -0,0 +1,71 @@
+package urlutil
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "lowercases host",
+			in:   "https://Example.COM/path",
+			want: "https://example.com/path",
+		},
+		{
+			name: "strips default https port",
+			in:   "https://example.com:443/path",
+			want: "https://example.com/path",
+		},
+		{
+			name: "strips default http port",
+			in:   "http://example.com:80/path",
+			want: "http://example.com/path",
+		},
+		{
+			name: "keeps non-default port",
+			in:   "http://example.com:8080/path",
+			want: "http://example.com:8080/path",
+		},
+		{
+			name: "collapses duplicate slashes",
+			in:   "https://example.com/a//b///c",
+			want: "https://example.com/a/b/c",
+		},
+		{
+			name: "sorts query keys",
+			in:   "https://example.com/path?b=2&a=1",
+			want: "https://example.com/path?a=1&b=2",
+		},
+		{
+			name: "drops fragment",
+			in:   "https://example.com/path#section",
+			want: "https://example.com/path",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Normalize(tc.in); got != tc.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalize_equivalentURLsMatch(t *testing.T) {
+	a := Normalize("HTTPS://Example.com:443/foo//bar?z=1&a=2#frag")
+	b := Normalize("https://example.com/foo/bar?a=2&z=1")
+
+	if a != b {
+		t.Errorf("expected equivalent URLs to normalize the same: %q != %q", a, b)
+	}
+}
+
+func TestNormalize_invalidURLReturnedUnchanged(t *testing.T) {
+	const in = "://not a url"
+	if got := Normalize(in); got != in {
+		t.Errorf("Normalize(%q) = %q, want unchanged input", in, got)
+	}
+}